@@ -0,0 +1,125 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Well-known label keys stamped by the install controller on every
+// resource it applies, so that watches on the resource kinds below can be
+// mapped back to the owning Install without relying on an ownerRef (which
+// cluster-scoped resources intentionally don't carry).
+const (
+	InstallNamespaceLabelKey = "serving.knative.dev/install-namespace"
+	InstallNameLabelKey      = "serving.knative.dev/install-name"
+)
+
+// InstallFinalizer is added to every Install on first reconcile so that its
+// manifest can be torn down, and cluster-scoped resources (which don't carry
+// an owner reference) refcounted against other Installs, before the object
+// itself is allowed to be removed.
+const InstallFinalizer = "serving.knative.dev/install-finalizer"
+
+// Condition types reported on an Install's Status.Conditions
+const (
+	// InstallApplied indicates whether the manifest has been applied to the cluster
+	InstallApplied = "InstallApplied"
+	// ComponentsReady indicates whether every tracked component has reached a ready state
+	ComponentsReady = "ComponentsReady"
+	// Degraded indicates that one or more tracked components are unhealthy
+	Degraded = "Degraded"
+	// PreflightFailed indicates that one or more preflight checks failed;
+	// the per-check conditions (e.g. ServerVersionCompatible,
+	// NetworkingCRDsPresent, NoConflictingInstall, RBACSufficient) explain which
+	PreflightFailed = "PreflightFailed"
+)
+
+// InstallStatus defines the observed state of Install
+type InstallStatus struct {
+	// Resources lists the "name (group/version, Kind=kind)" of every resource
+	// applied from the manifest. Superseded by the per-kind status below,
+	// but retained for existing clients that read it.
+	Resources []string `json:"resources,omitempty"`
+	// Version is the KNATIVE_SERVING_VERSION the operator was running when it last applied the manifest
+	Version string `json:"version,omitempty"`
+	// ManifestVersion is Version plus a hash of the parsed manifest documents.
+	// It changes whenever the operator image ships a new bundle, which is
+	// what drives re-applying an already-applied Install.
+	ManifestVersion string `json:"manifestVersion,omitempty"`
+	// AppliedResources identifies, by GVK and name, every resource applied
+	// from the manifest the last time ManifestVersion changed. It's diffed
+	// against the newly parsed manifest on the next change so resources
+	// dropped from the bundle can be garbage collected.
+	AppliedResources []ResourceRef `json:"appliedResources,omitempty"`
+
+	// Ready aggregates the per-kind statuses below: true only when every
+	// tracked resource reports ready.
+	Ready bool `json:"ready"`
+
+	Deployments  []DeploymentStatus  `json:"deployments,omitempty"`
+	DaemonSets   []DaemonSetStatus   `json:"daemonSets,omitempty"`
+	StatefulSets []StatefulSetStatus `json:"statefulSets,omitempty"`
+	Services     []ServiceStatus     `json:"services,omitempty"`
+	Ingresses    []IngressStatus     `json:"ingresses,omitempty"`
+	ConfigMaps   []ConfigMapStatus   `json:"configMaps,omitempty"`
+	Pods         []PodStatus         `json:"pods,omitempty"`
+
+	// Conditions holds the preflight conditions (the per-check ones and
+	// PreflightFailed), followed, once preflight passes, by the
+	// InstallApplied, ComponentsReady and Degraded conditions
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ResourceRef identifies a single manifest resource by its GVK and name, so
+// a resource no longer present in a newer manifest can be found and deleted.
+type ResourceRef struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// DeploymentStatus is the observed state of a single Deployment applied by the manifest
+type DeploymentStatus struct {
+	Name              string `json:"name"`
+	Replicas          int32  `json:"replicas"`
+	ReadyReplicas     int32  `json:"readyReplicas"`
+	UpdatedReplicas   int32  `json:"updatedReplicas"`
+	AvailableReplicas int32  `json:"availableReplicas"`
+}
+
+// DaemonSetStatus is the observed state of a single DaemonSet applied by the manifest
+type DaemonSetStatus struct {
+	Name                   string `json:"name"`
+	DesiredNumberScheduled int32  `json:"desiredNumberScheduled"`
+	NumberReady            int32  `json:"numberReady"`
+}
+
+// StatefulSetStatus is the observed state of a single StatefulSet applied by the manifest
+type StatefulSetStatus struct {
+	Name          string `json:"name"`
+	Replicas      int32  `json:"replicas"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+}
+
+// ServiceStatus is the observed state of a single Service applied by the manifest
+type ServiceStatus struct {
+	Name string `json:"name"`
+}
+
+// IngressStatus is the observed state of a single Ingress applied by the manifest
+type IngressStatus struct {
+	Name string `json:"name"`
+}
+
+// ConfigMapStatus is the observed state of a single ConfigMap applied by the manifest
+type ConfigMapStatus struct {
+	Name string `json:"name"`
+}
+
+// PodStatus is the observed state of a single Pod applied by the manifest
+type PodStatus struct {
+	Name  string `json:"name"`
+	Phase string `json:"phase"`
+	Ready bool   `json:"ready"`
+}
@@ -0,0 +1,16 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/scheme"
+)
+
+// SchemeGroupVersion is group version used to register these objects
+var SchemeGroupVersion = schema.GroupVersion{Group: "serving.knative.dev", Version: "v1alpha1"}
+
+// SchemeBuilder is used to add go types to the GroupVersionKind scheme
+var SchemeBuilder = &scheme.Builder{GroupVersion: SchemeGroupVersion}
+
+// AddToScheme is used for schema registrations in the controller package
+// and the CLI
+var AddToScheme = SchemeBuilder.AddToScheme
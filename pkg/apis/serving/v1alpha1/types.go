@@ -0,0 +1,63 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InstallSpec defines the desired state of Install. The manifest at
+// --filename is treated as a template: every field here except Ingress is
+// overlaid onto the matching unstructured objects before they're applied
+// (see pkg/controller/install's transformersFor); Ingress is only read by
+// the preflight checks.
+type InstallSpec struct {
+	// Registry overrides the image registry/repository prefix of every
+	// container image in the manifest, e.g. "quay.io/my-org"
+	Registry string `json:"registry,omitempty"`
+	// ImagePullSecrets is added to the PodSpec of every Deployment, DaemonSet
+	// and StatefulSet in the manifest
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Namespace overrides the namespace of every namespaced resource in the manifest
+	Namespace string `json:"namespace,omitempty"`
+	// Resources overrides compute resources of a manifest container, keyed by container name
+	Resources map[string]corev1.ResourceRequirements `json:"resources,omitempty"`
+	// NodeSelector is added to the PodSpec of every Deployment, DaemonSet and StatefulSet in the manifest
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations is added to the PodSpec of every Deployment, DaemonSet and StatefulSet in the manifest
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Affinity is added to the PodSpec of every Deployment, DaemonSet and StatefulSet in the manifest
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// ConfigMapData is merged into the data of a manifest ConfigMap, keyed by ConfigMap name
+	// (e.g. Knative Serving's config-autoscaler, config-network, config-observability, ...)
+	ConfigMapData map[string]map[string]string `json:"configMapData,omitempty"`
+	// Ingress selects the networking layer the cluster is expected to already
+	// have installed (e.g. "istio"); preflight checks that its CRDs are
+	// present before the manifest, which assumes they exist, is applied.
+	// Defaults to "istio".
+	Ingress string `json:"ingress,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Install is the Schema for the installs API
+// +k8s:openapi-gen=true
+type Install struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InstallSpec   `json:"spec,omitempty"`
+	Status InstallStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// InstallList contains a list of Install
+type InstallList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Install `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Install{}, &InstallList{})
+}
@@ -0,0 +1,76 @@
+package install
+
+import (
+	"testing"
+
+	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func stampedLabels(instance *servingv1alpha1.Install) map[string]string {
+	return map[string]string{
+		servingv1alpha1.InstallNamespaceLabelKey: instance.GetNamespace(),
+		servingv1alpha1.InstallNameLabelKey:      instance.GetName(),
+	}
+}
+
+// TestBuildStatusNotReadyForFreshlyCreatedDeployment is a regression test: a
+// prior version compared ReadyReplicas against Status.Replicas, which is
+// zero on a Deployment the controller hasn't synced yet, so Ready flipped
+// true immediately after Apply, before a single Pod existed.
+func TestBuildStatusNotReadyForFreshlyCreatedDeployment(t *testing.T) {
+	instance := &servingv1alpha1.Install{ObjectMeta: metav1.ObjectMeta{Name: "my-install", Namespace: "knative-serving"}}
+	replicas := int32(3)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "controller",
+			Namespace:  "knative-serving",
+			Labels:     stampedLabels(instance),
+			Generation: 1,
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+		// Status left zero-valued, as it is immediately after Create.
+	}
+	c := fake.NewFakeClient(deployment)
+
+	status, err := buildStatus(c, instance)
+	if err != nil {
+		t.Fatalf("buildStatus returned error: %v", err)
+	}
+	if status.Ready {
+		t.Errorf("Ready = true for a freshly created, unsynced Deployment; want false")
+	}
+}
+
+func TestBuildStatusReadyWhenAvailableMeetsDesiredReplicas(t *testing.T) {
+	instance := &servingv1alpha1.Install{ObjectMeta: metav1.ObjectMeta{Name: "my-install", Namespace: "knative-serving"}}
+	replicas := int32(3)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "controller",
+			Namespace:  "knative-serving",
+			Labels:     stampedLabels(instance),
+			Generation: 1,
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           3,
+			ReadyReplicas:      3,
+			AvailableReplicas:  3,
+			UpdatedReplicas:    3,
+		},
+	}
+	c := fake.NewFakeClient(deployment)
+
+	status, err := buildStatus(c, instance)
+	if err != nil {
+		t.Fatalf("buildStatus returned error: %v", err)
+	}
+	if !status.Ready {
+		t.Errorf("Ready = false for a fully available Deployment; want true")
+	}
+}
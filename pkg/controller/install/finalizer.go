@@ -0,0 +1,124 @@
+package install
+
+import (
+	"context"
+
+	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func hasFinalizer(instance *servingv1alpha1.Install) bool {
+	for _, f := range instance.GetFinalizers() {
+		if f == servingv1alpha1.InstallFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(instance *servingv1alpha1.Install) {
+	finalizers := instance.GetFinalizers()
+	for i, f := range finalizers {
+		if f == servingv1alpha1.InstallFinalizer {
+			instance.SetFinalizers(append(finalizers[:i], finalizers[i+1:]...))
+			return
+		}
+	}
+}
+
+// finalize tears down everything instance's manifest applied, skipping any
+// cluster-scoped resource another live Install still depends on, and only
+// removes InstallFinalizer once every resource is confirmed gone. It's
+// called instead of the normal reconcile body once instance has a deletion
+// timestamp.
+func (r *ReconcileInstall) finalize(instance *servingv1alpha1.Install) (reconcile.Result, error) {
+	if !hasFinalizer(instance) {
+		return reconcile.Result{}, nil
+	}
+
+	keep, err := r.clusterScopedRefsInUseElsewhere(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	skip := func(ref servingv1alpha1.ResourceRef) bool {
+		return ref.Namespace == "" && keep[refKey(ref)]
+	}
+	if err := r.config.Uninstall(skip); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	for _, ref := range r.resourceRefsToVerify(instance) {
+		if skip(ref) {
+			continue
+		}
+		exists, err := r.config.Exists(ref)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if exists {
+			log.Info("Waiting for resource to finish deleting", "type", ref.Kind, "name", ref.Name)
+			return reconcile.Result{Requeue: true}, nil
+		}
+	}
+
+	removeFinalizer(instance)
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// clusterScopedRefsInUseElsewhere returns the set of cluster-scoped
+// ResourceRefs (by refKey) applied by some other Install that isn't itself
+// being deleted, so instance's finalizer leaves them for whichever of those
+// Installs is deleted last.
+func (r *ReconcileInstall) clusterScopedRefsInUseElsewhere(instance *servingv1alpha1.Install) (map[string]bool, error) {
+	installList := &servingv1alpha1.InstallList{}
+	if err := r.client.List(context.TODO(), &client.ListOptions{}, installList); err != nil {
+		return nil, err
+	}
+	inUse := map[string]bool{}
+	for _, other := range installList.Items {
+		if other.GetNamespace() == instance.GetNamespace() && other.GetName() == instance.GetName() {
+			continue
+		}
+		if other.GetDeletionTimestamp() != nil {
+			continue
+		}
+		for _, ref := range other.Status.AppliedResources {
+			if ref.Namespace == "" {
+				inUse[refKey(ref)] = true
+			}
+		}
+	}
+	return inUse, nil
+}
+
+func refKey(ref servingv1alpha1.ResourceRef) string {
+	return ref.Group + "/" + ref.Version + "/" + ref.Kind + "/" + ref.Name
+}
+
+// resourceRefsToVerify returns the union of instance.Status.AppliedResources
+// (what was actually applied as of the last successful reconcile) and the
+// manifest's own ResourceRefs, so a resource added to the bundle between
+// that reconcile and the deletion timestamp is still waited on rather than
+// silently skipped.
+func (r *ReconcileInstall) resourceRefsToVerify(instance *servingv1alpha1.Install) []servingv1alpha1.ResourceRef {
+	seen := map[string]bool{}
+	var refs []servingv1alpha1.ResourceRef
+	for _, ref := range instance.Status.AppliedResources {
+		if !seen[refKey(ref)] {
+			seen[refKey(ref)] = true
+			refs = append(refs, ref)
+		}
+	}
+	for _, ref := range r.config.ResourceRefs() {
+		if !seen[refKey(ref)] {
+			seen[refKey(ref)] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
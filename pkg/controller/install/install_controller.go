@@ -3,15 +3,21 @@ package install
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
 	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
 	"github.com/jcrossley3/knative-serving-operator/pkg/manifests"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -37,10 +43,15 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	dc, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		log.Error(err, "Unable to create discovery client")
+	}
 	return &ReconcileInstall{
-		client: mgr.GetClient(),
-		scheme: mgr.GetScheme(),
-		config: manifests.NewYamlFile(*filename, mgr.GetConfig())}
+		client:    mgr.GetClient(),
+		scheme:    mgr.GetScheme(),
+		discovery: dc,
+		config:    manifests.NewYamlFile(*filename, mgr.GetConfig(), mgr.GetScheme())}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -57,6 +68,27 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch the kinds the manifest can contain so that an Install is
+	// re-reconciled whenever one of its applied resources changes.
+	// Resources are matched back to their owning Install by the labels
+	// manifests.YamlFile.Apply stamps on them, not by ownerRef, since
+	// cluster-scoped resources can't carry one.
+	trackedKinds := []runtime.Object{
+		&appsv1.Deployment{},
+		&appsv1.DaemonSet{},
+		&appsv1.StatefulSet{},
+		&corev1.Service{},
+		&extensionsv1beta1.Ingress{},
+		&corev1.ConfigMap{},
+		&corev1.Pod{},
+	}
+	for _, kind := range trackedKinds {
+		err = c.Watch(&source.Kind{Type: kind}, mapToInstall, stampedPredicate)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Auto-create Install
 	if *autoinstall {
 		ns, _ := k8sutil.GetWatchNamespace()
@@ -71,9 +103,10 @@ var _ reconcile.Reconciler = &ReconcileInstall{}
 type ReconcileInstall struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client client.Client
-	scheme *runtime.Scheme
-	config *manifests.YamlFile
+	client    client.Client
+	scheme    *runtime.Scheme
+	discovery discovery.DiscoveryInterface
+	config    *manifests.YamlFile
 }
 
 // Reconcile reads that state of the cluster for a Install object and makes changes based on the state read
@@ -91,26 +124,70 @@ func (r *ReconcileInstall) Reconcile(request reconcile.Request) (reconcile.Resul
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
-			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
-			// Return and don't requeue
-			r.config.Delete()
+			// InstallFinalizer guarantees the manifest was already torn down
+			// before the object itself could disappear, so there's nothing
+			// left to do.
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
-	if instance.Status.Resources != nil {
-		// we've already successfully applied our YAML
+
+	if !instance.GetDeletionTimestamp().IsZero() {
+		return r.finalize(instance)
+	}
+	if !hasFinalizer(instance) {
+		instance.SetFinalizers(append(instance.GetFinalizers(), servingv1alpha1.InstallFinalizer))
+		if err := r.client.Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
 		return reconcile.Result{}, nil
 	}
-	// Apply the resources in the YAML file
-	err = r.config.Apply(instance)
+
+	// Run preflight checks before touching the manifest at all; a failure
+	// here is retried on a fixed interval rather than applied immediately,
+	// since most of them (missing CRDs, a conflicting install) need an
+	// operator to intervene rather than clearing on the next watch event.
+	ok, preflightConditions := r.preflight(instance)
+	if !ok {
+		instance.Status.Conditions = preflightConditions
+		if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+			reqLogger.Error(err, "Failed to update status")
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	// manifestVersion changes whenever the bundled manifest (or the running
+	// operator's KNATIVE_SERVING_VERSION) changes, which is what drives
+	// re-applying an Install that was already successfully applied.
+	manifestVersion := fmt.Sprintf("%s-%s", getKnativeServingVersion(), r.config.Hash())
+	if instance.Status.ManifestVersion != manifestVersion {
+		previous := instance.Status.AppliedResources
+		ownerRef := metav1.NewControllerRef(instance, servingv1alpha1.SchemeGroupVersion.WithKind("Install"))
+		r.config.Transformers = transformersFor(instance.Spec)
+		if err := r.config.Apply(instance, ownerRef); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.config.DeleteStale(previous); err != nil {
+			return reconcile.Result{}, err
+		}
+		instance.Status.Resources = r.config.ResourceNames()
+		instance.Status.AppliedResources = r.config.ResourceRefs()
+		instance.Status.Version = getKnativeServingVersion()
+		instance.Status.ManifestVersion = manifestVersion
+	}
+
+	// Roll up the observed state of everything Apply stamped for this
+	// Install, whether this reconcile was triggered by the Install itself
+	// or by one of its children changing.
+	status, err := buildStatus(r.client, instance)
 	if err != nil {
+		reqLogger.Error(err, "Failed to compute component status")
 		return reconcile.Result{}, err
 	}
-	// Update status
-	instance.Status.Resources = r.config.ResourceNames()
-	instance.Status.Version = getKnativeServingVersion()
+	instance.Status = *mergeStatus(instance.Status, status, preflightConditions)
+
 	err = r.client.Status().Update(context.TODO(), instance)
 	if err != nil {
 		reqLogger.Error(err, "Failed to update status")
@@ -0,0 +1,229 @@
+package install
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// workloadPodSpecPath returns the path to the PodSpec within an
+// unstructured object of a kind the manifest is expected to carry a
+// PodSpec for, or nil if the kind doesn't carry one.
+func workloadPodSpecPath(kind string) []string {
+	switch kind {
+	case "Deployment", "DaemonSet", "StatefulSet", "Job", "ReplicaSet":
+		return []string{"spec", "template", "spec"}
+	case "Pod":
+		return []string{"spec"}
+	default:
+		return nil
+	}
+}
+
+// transformersFor builds the manifests.YamlFile Transformers that overlay
+// spec onto the parsed manifest.
+func transformersFor(spec servingv1alpha1.InstallSpec) []func(*unstructured.Unstructured) error {
+	var ts []func(*unstructured.Unstructured) error
+	if spec.Namespace != "" {
+		ts = append(ts, namespaceOverlay(spec.Namespace))
+	}
+	if spec.Registry != "" {
+		ts = append(ts, registryOverlay(spec.Registry))
+	}
+	if len(spec.ImagePullSecrets) > 0 {
+		ts = append(ts, imagePullSecretsOverlay(spec.ImagePullSecrets))
+	}
+	if len(spec.Resources) > 0 {
+		ts = append(ts, resourcesOverlay(spec.Resources))
+	}
+	if len(spec.NodeSelector) > 0 {
+		ts = append(ts, nodeSelectorOverlay(spec.NodeSelector))
+	}
+	if len(spec.Tolerations) > 0 {
+		ts = append(ts, tolerationsOverlay(spec.Tolerations))
+	}
+	if spec.Affinity != nil {
+		ts = append(ts, affinityOverlay(spec.Affinity))
+	}
+	if len(spec.ConfigMapData) > 0 {
+		ts = append(ts, configMapDataOverlay(spec.ConfigMapData))
+	}
+	return ts
+}
+
+func namespaceOverlay(ns string) func(*unstructured.Unstructured) error {
+	return func(u *unstructured.Unstructured) error {
+		if u.GetNamespace() != "" {
+			u.SetNamespace(ns)
+		}
+		return nil
+	}
+}
+
+func registryOverlay(registry string) func(*unstructured.Unstructured) error {
+	registry = strings.TrimSuffix(registry, "/")
+	return func(u *unstructured.Unstructured) error {
+		return mutateContainers(u, func(c map[string]interface{}) error {
+			image, _, err := unstructured.NestedString(c, "image")
+			if err != nil || image == "" {
+				return err
+			}
+			c["image"] = fmt.Sprintf("%s/%s", registry, path.Base(image))
+			return nil
+		})
+	}
+}
+
+func imagePullSecretsOverlay(refs []corev1.LocalObjectReference) func(*unstructured.Unstructured) error {
+	items := make([]interface{}, len(refs))
+	for i := range refs {
+		items[i] = map[string]interface{}{"name": refs[i].Name}
+	}
+	return func(u *unstructured.Unstructured) error {
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			spec["imagePullSecrets"] = items
+			return nil
+		})
+	}
+}
+
+func resourcesOverlay(resources map[string]corev1.ResourceRequirements) func(*unstructured.Unstructured) error {
+	return func(u *unstructured.Unstructured) error {
+		return mutateContainers(u, func(c map[string]interface{}) error {
+			name, _, err := unstructured.NestedString(c, "name")
+			if err != nil {
+				return err
+			}
+			req, ok := resources[name]
+			if !ok {
+				return nil
+			}
+			r, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&req)
+			if err != nil {
+				return err
+			}
+			c["resources"] = r
+			return nil
+		})
+	}
+}
+
+func nodeSelectorOverlay(nodeSelector map[string]string) func(*unstructured.Unstructured) error {
+	selector := make(map[string]interface{}, len(nodeSelector))
+	for k, v := range nodeSelector {
+		selector[k] = v
+	}
+	return func(u *unstructured.Unstructured) error {
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			spec["nodeSelector"] = selector
+			return nil
+		})
+	}
+}
+
+func tolerationsOverlay(tolerations []corev1.Toleration) func(*unstructured.Unstructured) error {
+	return func(u *unstructured.Unstructured) error {
+		items := make([]interface{}, 0, len(tolerations))
+		for i := range tolerations {
+			t, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&tolerations[i])
+			if err != nil {
+				return err
+			}
+			items = append(items, t)
+		}
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			spec["tolerations"] = items
+			return nil
+		})
+	}
+}
+
+func affinityOverlay(affinity *corev1.Affinity) func(*unstructured.Unstructured) error {
+	return func(u *unstructured.Unstructured) error {
+		a, err := runtime.DefaultUnstructuredConverter.ToUnstructured(affinity)
+		if err != nil {
+			return err
+		}
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			spec["affinity"] = a
+			return nil
+		})
+	}
+}
+
+func configMapDataOverlay(data map[string]map[string]string) func(*unstructured.Unstructured) error {
+	return func(u *unstructured.Unstructured) error {
+		if u.GetKind() != "ConfigMap" {
+			return nil
+		}
+		overlay, ok := data[u.GetName()]
+		if !ok {
+			return nil
+		}
+		existing, _, err := unstructured.NestedStringMap(u.Object, "data")
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			existing = map[string]string{}
+		}
+		for k, v := range overlay {
+			existing[k] = v
+		}
+		merged := make(map[string]interface{}, len(existing))
+		for k, v := range existing {
+			merged[k] = v
+		}
+		return unstructured.SetNestedMap(u.Object, merged, "data")
+	}
+}
+
+// mutatePodSpec applies mutate to the PodSpec of u, if u's kind carries one.
+func mutatePodSpec(u *unstructured.Unstructured, mutate func(map[string]interface{}) error) error {
+	p := workloadPodSpecPath(u.GetKind())
+	if p == nil {
+		return nil
+	}
+	podSpec, found, err := unstructured.NestedMap(u.Object, p...)
+	if err != nil {
+		return err
+	}
+	if !found {
+		podSpec = map[string]interface{}{}
+	}
+	if err := mutate(podSpec); err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(u.Object, podSpec, p...)
+}
+
+// mutateContainers applies mutate to every entry of spec.containers and
+// spec.initContainers of u, if u's kind carries a PodSpec.
+func mutateContainers(u *unstructured.Unstructured, mutate func(map[string]interface{}) error) error {
+	return mutatePodSpec(u, func(spec map[string]interface{}) error {
+		for _, field := range []string{"containers", "initContainers"} {
+			containers, ok := spec[field].([]interface{})
+			if !ok {
+				continue
+			}
+			for i, entry := range containers {
+				c, ok := entry.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if err := mutate(c); err != nil {
+					return err
+				}
+				containers[i] = c
+			}
+			spec[field] = containers
+		}
+		return nil
+	})
+}
@@ -0,0 +1,147 @@
+package install
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func deployment(image string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "controller"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "controller", "image": image},
+					},
+				},
+			},
+		},
+	}}
+}
+
+// firstContainer returns the spec of u's first container, as overlaid.
+func firstContainer(t *testing.T, u *unstructured.Unstructured) map[string]interface{} {
+	containers, found, err := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+	if err != nil || !found || len(containers) == 0 {
+		t.Fatalf("containers not found: found=%v err=%v", found, err)
+	}
+	c, ok := containers[0].(map[string]interface{})
+	if !ok {
+		t.Fatalf("containers[0] is not a map: %v", containers[0])
+	}
+	return c
+}
+
+func TestRegistryOverlayRewritesImageKeepingBaseName(t *testing.T) {
+	u := deployment("gcr.io/knative-releases/controller:v0.10.0")
+
+	if err := registryOverlay("quay.io/my-org/")(u); err != nil {
+		t.Fatalf("registryOverlay returned error: %v", err)
+	}
+
+	image, _ := firstContainer(t, u)["image"].(string)
+	if want := "quay.io/my-org/controller:v0.10.0"; image != want {
+		t.Errorf("image = %q, want %q", image, want)
+	}
+}
+
+func TestRegistryOverlayIgnoresKindsWithoutPodSpec(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config-network"},
+	}}
+
+	if err := registryOverlay("quay.io/my-org")(u); err != nil {
+		t.Fatalf("registryOverlay returned error: %v", err)
+	}
+}
+
+func TestResourcesOverlayAppliesOnlyToNamedContainer(t *testing.T) {
+	u := deployment("gcr.io/knative-releases/controller:v0.10.0")
+	overlay := resourcesOverlay(map[string]corev1.ResourceRequirements{
+		"controller": {
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+		},
+		"sidecar": {
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+	})
+
+	if err := overlay(u); err != nil {
+		t.Fatalf("resourcesOverlay returned error: %v", err)
+	}
+
+	resources, _, err := unstructured.NestedMap(firstContainer(t, u), "resources")
+	if err != nil {
+		t.Fatalf("resources: %v", err)
+	}
+	mem, _, _ := unstructured.NestedString(resources, "requests", "memory")
+	if mem != "128Mi" {
+		t.Errorf("requests.memory = %q, want 128Mi", mem)
+	}
+}
+
+func TestNodeSelectorOverlaySetsPodSpecField(t *testing.T) {
+	u := deployment("gcr.io/knative-releases/controller:v0.10.0")
+
+	if err := nodeSelectorOverlay(map[string]string{"disktype": "ssd"})(u); err != nil {
+		t.Fatalf("nodeSelectorOverlay returned error: %v", err)
+	}
+
+	v, found, _ := unstructured.NestedString(u.Object, "spec", "template", "spec", "nodeSelector", "disktype")
+	if !found || v != "ssd" {
+		t.Errorf("nodeSelector.disktype = %q, found=%v, want ssd", v, found)
+	}
+}
+
+func TestConfigMapDataOverlayMergesWithoutDroppingExistingKeys(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config-autoscaler"},
+		"data":       map[string]interface{}{"stable-window": "60s"},
+	}}
+	overlay := configMapDataOverlay(map[string]map[string]string{
+		"config-autoscaler": {"panic-window-percentage": "10.0"},
+	})
+
+	if err := overlay(u); err != nil {
+		t.Fatalf("configMapDataOverlay returned error: %v", err)
+	}
+
+	data, _, _ := unstructured.NestedStringMap(u.Object, "data")
+	if data["stable-window"] != "60s" {
+		t.Errorf("existing key stable-window dropped: %v", data)
+	}
+	if data["panic-window-percentage"] != "10.0" {
+		t.Errorf("overlay key panic-window-percentage missing: %v", data)
+	}
+}
+
+func TestConfigMapDataOverlaySkipsUnmatchedConfigMap(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config-network"},
+		"data":       map[string]interface{}{"foo": "bar"},
+	}}
+	overlay := configMapDataOverlay(map[string]map[string]string{
+		"config-autoscaler": {"panic-window-percentage": "10.0"},
+	})
+
+	if err := overlay(u); err != nil {
+		t.Fatalf("configMapDataOverlay returned error: %v", err)
+	}
+
+	data, _, _ := unstructured.NestedStringMap(u.Object, "data")
+	if len(data) != 1 || data["foo"] != "bar" {
+		t.Errorf("data mutated for unmatched ConfigMap: %v", data)
+	}
+}
@@ -0,0 +1,203 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultIngress is assumed when InstallSpec.Ingress is unset.
+const defaultIngress = "istio"
+
+// requiredNetworkingCRDs are the CRDs a networking layer must already have
+// installed for the manifest, which assumes one of them is present, to work.
+var requiredNetworkingCRDs = map[string][]schema.GroupVersionResource{
+	"istio": {
+		{Group: "networking.istio.io", Version: "v1alpha3", Resource: "virtualservices"},
+		{Group: "networking.istio.io", Version: "v1alpha3", Resource: "gateways"},
+	},
+}
+
+// minServerVersion/maxServerVersion bound the Kubernetes versions the
+// bundled manifest is known to work against.
+const (
+	minServerVersionMajor, minServerVersionMinor = 1, 13
+	maxServerVersionMajor, maxServerVersionMinor = 1, 24
+)
+
+// preflight runs every check that must pass before the manifest is applied,
+// returning whether they all passed and the conditions (one per check, plus
+// a PreflightFailed summary) to record on the Install.
+func (r *ReconcileInstall) preflight(instance *servingv1alpha1.Install) (bool, []metav1.Condition) {
+	now := metav1.Now()
+	checks := []metav1.Condition{
+		r.checkServerVersion(),
+		r.checkNetworkingCRDs(instance),
+		r.checkConflictingInstall(instance),
+		r.checkRBAC(),
+	}
+	ok := true
+	var failedReasons []string
+	for i := range checks {
+		checks[i].LastTransitionTime = now
+		if checks[i].Status == metav1.ConditionFalse {
+			ok = false
+			failedReasons = append(failedReasons, checks[i].Reason)
+		}
+	}
+	summary := metav1.Condition{Type: servingv1alpha1.PreflightFailed, LastTransitionTime: now}
+	if ok {
+		summary.Status = metav1.ConditionFalse
+		summary.Reason = "PreflightPassed"
+	} else {
+		summary.Status = metav1.ConditionTrue
+		summary.Reason = strings.Join(failedReasons, ",")
+		summary.Message = "One or more preflight checks failed; see the per-check conditions for detail"
+	}
+	return ok, append(checks, summary)
+}
+
+func (r *ReconcileInstall) checkServerVersion() metav1.Condition {
+	cond := metav1.Condition{Type: "ServerVersionCompatible", Status: metav1.ConditionTrue, Reason: "VersionInRange"}
+	v, err := r.discovery.ServerVersion()
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ServerVersionUnknown"
+		cond.Message = err.Error()
+		return cond
+	}
+	major, minor := parseVersionComponent(v.Major), parseVersionComponent(v.Minor)
+	below := major < minServerVersionMajor || (major == minServerVersionMajor && minor < minServerVersionMinor)
+	above := major > maxServerVersionMajor || (major == maxServerVersionMajor && minor > maxServerVersionMinor)
+	if below || above {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ServerVersionOutOfRange"
+		cond.Message = fmt.Sprintf("Kubernetes %s.%s is outside the supported range %d.%d-%d.%d",
+			v.Major, v.Minor, minServerVersionMajor, minServerVersionMinor, maxServerVersionMajor, maxServerVersionMinor)
+	}
+	return cond
+}
+
+// parseVersionComponent strips the "+" discovery sometimes appends (e.g. "18+") before parsing.
+func parseVersionComponent(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSuffix(s, "+"))
+	return n
+}
+
+func (r *ReconcileInstall) checkNetworkingCRDs(instance *servingv1alpha1.Install) metav1.Condition {
+	cond := metav1.Condition{Type: "NetworkingCRDsPresent", Status: metav1.ConditionTrue, Reason: "CRDsFound"}
+	ingress := instance.Spec.Ingress
+	if ingress == "" {
+		ingress = defaultIngress
+	}
+	crds, ok := requiredNetworkingCRDs[ingress]
+	if !ok {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "UnknownIngress"
+		cond.Message = fmt.Sprintf("no known required CRDs for ingress %q", ingress)
+		return cond
+	}
+	for _, gvr := range crds {
+		resources, err := r.discovery.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				cond.Status = metav1.ConditionFalse
+				cond.Reason = "MissingNetworkingCRDs"
+				cond.Message = fmt.Sprintf("%s.%s not found; is %s installed?", gvr.Resource, gvr.Group, ingress)
+				return cond
+			}
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = "NetworkingCRDsCheckFailed"
+			cond.Message = err.Error()
+			return cond
+		}
+		if !hasResource(resources.APIResources, gvr.Resource) {
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = "MissingNetworkingCRDs"
+			cond.Message = fmt.Sprintf("%s.%s not found; is %s installed?", gvr.Resource, gvr.Group, ingress)
+			return cond
+		}
+	}
+	return cond
+}
+
+func hasResource(resources []metav1.APIResource, name string) bool {
+	for _, r := range resources {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ReconcileInstall) checkConflictingInstall(instance *servingv1alpha1.Install) metav1.Condition {
+	cond := metav1.Condition{Type: "NoConflictingInstall", Status: metav1.ConditionTrue, Reason: "NoConflict"}
+	ns := &corev1.Namespace{}
+	err := r.client.Get(context.TODO(), client.ObjectKey{Name: "knative-serving"}, ns)
+	if apierrors.IsNotFound(err) {
+		return cond
+	}
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ConflictCheckFailed"
+		cond.Message = err.Error()
+		return cond
+	}
+	// Any Install's Apply stamps the namespace with InstallNameLabelKey, and
+	// multiple Installs across namespaces are expected to share it (see the
+	// finalizer's cluster-scoped refcounting), so only its absence indicates
+	// a foreign, non-operator-managed install rather than one of ours.
+	if _, ok := ns.Labels[servingv1alpha1.InstallNameLabelKey]; !ok {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ConflictingInstall"
+		cond.Message = "namespace knative-serving already exists and isn't managed by this operator"
+	}
+	return cond
+}
+
+func (r *ReconcileInstall) checkRBAC() metav1.Condition {
+	cond := metav1.Condition{Type: "RBACSufficient", Status: metav1.ConditionTrue, Reason: "RBACSufficient"}
+	gvrs, err := r.config.GroupVersionResources()
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "RBACCheckFailed"
+		cond.Message = err.Error()
+		return cond
+	}
+	var missing []string
+	for _, gvr := range gvrs {
+		sar := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:    gvr.Group,
+					Version:  gvr.Version,
+					Resource: gvr.Resource,
+					Verb:     "create",
+				},
+			},
+		}
+		if err := r.client.Create(context.TODO(), sar); err != nil {
+			missing = append(missing, gvr.String())
+			continue
+		}
+		if !sar.Status.Allowed {
+			missing = append(missing, gvr.String())
+		}
+	}
+	if len(missing) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "InsufficientRBAC"
+		cond.Message = fmt.Sprintf("missing create permission for: %s", strings.Join(missing, ", "))
+	}
+	return cond
+}
@@ -0,0 +1,66 @@
+package install
+
+import (
+	"testing"
+
+	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeReconciler(objs ...runtime.Object) *ReconcileInstall {
+	return &ReconcileInstall{client: fake.NewFakeClient(objs...)}
+}
+
+// TestCheckConflictingInstallAllowsSharedNamespace is a regression test: a
+// prior version required the knative-serving namespace's InstallNameLabelKey
+// to equal the reconciling Install's own name, but every Install applies the
+// same manifest, which stamps that shared namespace with whichever Install
+// happened to create it first - so any second Install, in any namespace,
+// would fail preflight forever. Only a namespace with no stamp at all (owned
+// by something other than this operator) should be flagged.
+func TestCheckConflictingInstallAllowsSharedNamespace(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "knative-serving",
+			Labels: map[string]string{servingv1alpha1.InstallNameLabelKey: "first-install"},
+		},
+	}
+	r := newFakeReconciler(ns)
+	second := &servingv1alpha1.Install{ObjectMeta: metav1.ObjectMeta{Name: "second-install", Namespace: "other-ns"}}
+
+	cond := r.checkConflictingInstall(second)
+
+	if cond.Status != metav1.ConditionTrue {
+		t.Errorf("Status = %v, want %v (message: %s)", cond.Status, metav1.ConditionTrue, cond.Message)
+	}
+}
+
+func TestCheckConflictingInstallFlagsUnstampedNamespace(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "knative-serving"}}
+	r := newFakeReconciler(ns)
+	instance := &servingv1alpha1.Install{ObjectMeta: metav1.ObjectMeta{Name: "my-install", Namespace: "ns"}}
+
+	cond := r.checkConflictingInstall(instance)
+
+	if cond.Status != metav1.ConditionFalse {
+		t.Errorf("Status = %v, want %v", cond.Status, metav1.ConditionFalse)
+	}
+	if cond.Reason != "ConflictingInstall" {
+		t.Errorf("Reason = %q, want %q", cond.Reason, "ConflictingInstall")
+	}
+}
+
+func TestCheckConflictingInstallPassesWhenNamespaceAbsent(t *testing.T) {
+	r := newFakeReconciler()
+	instance := &servingv1alpha1.Install{ObjectMeta: metav1.ObjectMeta{Name: "my-install", Namespace: "ns"}}
+
+	cond := r.checkConflictingInstall(instance)
+
+	if cond.Status != metav1.ConditionTrue {
+		t.Errorf("Status = %v, want %v (message: %s)", cond.Status, metav1.ConditionTrue, cond.Message)
+	}
+}
@@ -0,0 +1,195 @@
+package install
+
+import (
+	"context"
+
+	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// stampedPredicate only lets events through for objects the install
+// controller itself stamped via manifests.YamlFile.Apply, so watches on
+// common kinds like Pod don't cause reconciles for unrelated objects.
+var stampedPredicate = predicate.Funcs{
+	CreateFunc:  func(e event.CreateEvent) bool { return isStamped(e.Meta) },
+	UpdateFunc:  func(e event.UpdateEvent) bool { return isStamped(e.MetaNew) },
+	DeleteFunc:  func(e event.DeleteEvent) bool { return isStamped(e.Meta) },
+	GenericFunc: func(e event.GenericEvent) bool { return isStamped(e.Meta) },
+}
+
+func isStamped(meta metav1.Object) bool {
+	_, ok := meta.GetLabels()[servingv1alpha1.InstallNameLabelKey]
+	return ok
+}
+
+// mapToInstall turns a stamped child object back into a reconcile.Request
+// for the Install that owns it, using the labels applied by Apply rather
+// than an owner reference (which cluster-scoped children don't have).
+var mapToInstall = &handler.EnqueueRequestsFromMapFunc{
+	ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+		labels := o.Meta.GetLabels()
+		name, ok := labels[servingv1alpha1.InstallNameLabelKey]
+		if !ok {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{
+			Namespace: labels[servingv1alpha1.InstallNamespaceLabelKey],
+			Name:      name,
+		}}}
+	}),
+}
+
+// installSelector returns the label selector matching every resource Apply
+// stamped for the given Install.
+func installSelector(instance *servingv1alpha1.Install) client.MatchingLabels {
+	return client.MatchingLabels{
+		servingv1alpha1.InstallNamespaceLabelKey: instance.GetNamespace(),
+		servingv1alpha1.InstallNameLabelKey:      instance.GetName(),
+	}
+}
+
+// buildStatus lists every stamped resource of the tracked kinds and rolls
+// their observed state up into an InstallStatus, including the aggregate
+// Ready flag.
+func buildStatus(c client.Client, instance *servingv1alpha1.Install) (*servingv1alpha1.InstallStatus, error) {
+	status := &servingv1alpha1.InstallStatus{Ready: true}
+	selector := installSelector(instance)
+
+	deployments := &appsv1.DeploymentList{}
+	if err := c.List(context.TODO(), &client.ListOptions{LabelSelector: selector.AsSelector()}, deployments); err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		ready := d.Status.ObservedGeneration >= d.Generation && d.Status.AvailableReplicas >= desiredReplicas(d.Spec.Replicas)
+		status.Ready = status.Ready && ready
+		status.Deployments = append(status.Deployments, servingv1alpha1.DeploymentStatus{
+			Name:              d.Name,
+			Replicas:          d.Status.Replicas,
+			ReadyReplicas:     d.Status.ReadyReplicas,
+			UpdatedReplicas:   d.Status.UpdatedReplicas,
+			AvailableReplicas: d.Status.AvailableReplicas,
+		})
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := c.List(context.TODO(), &client.ListOptions{LabelSelector: selector.AsSelector()}, daemonSets); err != nil {
+		return nil, err
+	}
+	for _, d := range daemonSets.Items {
+		// DesiredNumberScheduled, like ReadyReplicas on a Deployment, is only
+		// trustworthy once the daemon set controller has synced this spec;
+		// before that it reads 0 and would otherwise report ready immediately.
+		ready := d.Status.ObservedGeneration >= d.Generation && d.Status.NumberReady >= d.Status.DesiredNumberScheduled
+		status.Ready = status.Ready && ready
+		status.DaemonSets = append(status.DaemonSets, servingv1alpha1.DaemonSetStatus{
+			Name:                   d.Name,
+			DesiredNumberScheduled: d.Status.DesiredNumberScheduled,
+			NumberReady:            d.Status.NumberReady,
+		})
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := c.List(context.TODO(), &client.ListOptions{LabelSelector: selector.AsSelector()}, statefulSets); err != nil {
+		return nil, err
+	}
+	for _, s := range statefulSets.Items {
+		ready := s.Status.ObservedGeneration >= s.Generation && s.Status.ReadyReplicas >= desiredReplicas(s.Spec.Replicas)
+		status.Ready = status.Ready && ready
+		status.StatefulSets = append(status.StatefulSets, servingv1alpha1.StatefulSetStatus{
+			Name:          s.Name,
+			Replicas:      s.Status.Replicas,
+			ReadyReplicas: s.Status.ReadyReplicas,
+		})
+	}
+
+	services := &corev1.ServiceList{}
+	if err := c.List(context.TODO(), &client.ListOptions{LabelSelector: selector.AsSelector()}, services); err != nil {
+		return nil, err
+	}
+	for _, s := range services.Items {
+		status.Services = append(status.Services, servingv1alpha1.ServiceStatus{Name: s.Name})
+	}
+
+	ingresses := &extensionsv1beta1.IngressList{}
+	if err := c.List(context.TODO(), &client.ListOptions{LabelSelector: selector.AsSelector()}, ingresses); err != nil {
+		return nil, err
+	}
+	for _, i := range ingresses.Items {
+		status.Ingresses = append(status.Ingresses, servingv1alpha1.IngressStatus{Name: i.Name})
+	}
+
+	configMaps := &corev1.ConfigMapList{}
+	if err := c.List(context.TODO(), &client.ListOptions{LabelSelector: selector.AsSelector()}, configMaps); err != nil {
+		return nil, err
+	}
+	for _, cm := range configMaps.Items {
+		status.ConfigMaps = append(status.ConfigMaps, servingv1alpha1.ConfigMapStatus{Name: cm.Name})
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.List(context.TODO(), &client.ListOptions{LabelSelector: selector.AsSelector()}, pods); err != nil {
+		return nil, err
+	}
+	for _, p := range pods.Items {
+		ready := p.Status.Phase == corev1.PodRunning
+		status.Ready = status.Ready && ready
+		status.Pods = append(status.Pods, servingv1alpha1.PodStatus{
+			Name:  p.Name,
+			Phase: string(p.Status.Phase),
+			Ready: ready,
+		})
+	}
+
+	return status, nil
+}
+
+// desiredReplicas returns a workload's spec.replicas, defaulting to 1 the
+// same way the apiserver does when the field is left unset.
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// mergeStatus folds the fields buildStatus doesn't compute itself -
+// Resources, AppliedResources, Version and ManifestVersion - from prev into
+// status, and appends the InstallApplied/ComponentsReady/Degraded
+// conditions derived from status after the preflight ones. It's what
+// Reconcile uses to turn a freshly built InstallStatus into the one that
+// replaces instance.Status, so a round trip through it never drops the
+// bookkeeping Apply/DeleteStale depend on.
+func mergeStatus(prev servingv1alpha1.InstallStatus, status *servingv1alpha1.InstallStatus, preflightConditions []metav1.Condition) *servingv1alpha1.InstallStatus {
+	status.Resources = prev.Resources
+	status.AppliedResources = prev.AppliedResources
+	status.Version = prev.Version
+	status.ManifestVersion = prev.ManifestVersion
+	status.Conditions = append(preflightConditions, conditionsFor(status)...)
+	return status
+}
+
+// conditionsFor derives the InstallApplied, ComponentsReady and Degraded
+// conditions from the just-computed status.
+func conditionsFor(status *servingv1alpha1.InstallStatus) []metav1.Condition {
+	componentsReady := metav1.ConditionFalse
+	degraded := metav1.ConditionTrue
+	if status.Ready {
+		componentsReady = metav1.ConditionTrue
+		degraded = metav1.ConditionFalse
+	}
+	now := metav1.Now()
+	return []metav1.Condition{
+		{Type: servingv1alpha1.InstallApplied, Status: metav1.ConditionTrue, Reason: "ManifestApplied", LastTransitionTime: now},
+		{Type: servingv1alpha1.ComponentsReady, Status: componentsReady, Reason: "ComponentStatusObserved", LastTransitionTime: now},
+		{Type: servingv1alpha1.Degraded, Status: degraded, Reason: "ComponentStatusObserved", LastTransitionTime: now},
+	}
+}
@@ -0,0 +1,80 @@
+package install
+
+import (
+	"testing"
+
+	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestMergeStatusPreservesManifestBookkeeping is a regression test for a bug
+// where Reconcile replaced instance.Status wholesale with buildStatus's
+// result, dropping ManifestVersion and AppliedResources because buildStatus
+// never sets them. That made the ManifestVersion gate in Reconcile always
+// true (re-applying the manifest on every reconcile) and made
+// DeleteStale always see a nil previous list (never garbage collecting).
+func TestMergeStatusPreservesManifestBookkeeping(t *testing.T) {
+	prev := servingv1alpha1.InstallStatus{
+		Resources:       []string{"foo (v1, Kind=ConfigMap)"},
+		Version:         "v0.10.0",
+		ManifestVersion: "v0.10.0-abc123",
+		AppliedResources: []servingv1alpha1.ResourceRef{
+			{Group: "", Version: "v1", Kind: "ConfigMap", Namespace: "knative-serving", Name: "foo"},
+		},
+	}
+	fresh := &servingv1alpha1.InstallStatus{Ready: true}
+
+	merged := mergeStatus(prev, fresh, nil)
+
+	if merged.ManifestVersion != prev.ManifestVersion {
+		t.Errorf("ManifestVersion = %q, want %q", merged.ManifestVersion, prev.ManifestVersion)
+	}
+	if merged.Version != prev.Version {
+		t.Errorf("Version = %q, want %q", merged.Version, prev.Version)
+	}
+	if len(merged.AppliedResources) != 1 || merged.AppliedResources[0].Name != "foo" {
+		t.Errorf("AppliedResources = %v, want %v", merged.AppliedResources, prev.AppliedResources)
+	}
+	if len(merged.Resources) != 1 || merged.Resources[0] != prev.Resources[0] {
+		t.Errorf("Resources = %v, want %v", merged.Resources, prev.Resources)
+	}
+}
+
+func TestMergeStatusAppendsPreflightBeforeComponentConditions(t *testing.T) {
+	preflight := []metav1.Condition{
+		{Type: servingv1alpha1.PreflightFailed, Status: metav1.ConditionFalse, Reason: "PreflightPassed"},
+	}
+	status := &servingv1alpha1.InstallStatus{Ready: true}
+
+	merged := mergeStatus(servingv1alpha1.InstallStatus{}, status, preflight)
+
+	if len(merged.Conditions) != 1+3 {
+		t.Fatalf("len(Conditions) = %d, want %d", len(merged.Conditions), 1+3)
+	}
+	if merged.Conditions[0].Type != servingv1alpha1.PreflightFailed {
+		t.Errorf("Conditions[0].Type = %q, want %q", merged.Conditions[0].Type, servingv1alpha1.PreflightFailed)
+	}
+}
+
+func TestConditionsForDegradedWhenNotReady(t *testing.T) {
+	status := &servingv1alpha1.InstallStatus{Ready: false}
+
+	conditions := conditionsFor(status)
+
+	var degraded, componentsReady metav1.Condition
+	for _, c := range conditions {
+		switch c.Type {
+		case servingv1alpha1.Degraded:
+			degraded = c
+		case servingv1alpha1.ComponentsReady:
+			componentsReady = c
+		}
+	}
+	if degraded.Status != metav1.ConditionTrue {
+		t.Errorf("Degraded.Status = %v, want %v", degraded.Status, metav1.ConditionTrue)
+	}
+	if componentsReady.Status != metav1.ConditionFalse {
+		t.Errorf("ComponentsReady.Status = %v, want %v", componentsReady.Status, metav1.ConditionFalse)
+	}
+}
@@ -2,61 +2,275 @@ package manifests
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	servingv1alpha1 "github.com/jcrossley3/knative-serving-operator/pkg/apis/serving/v1alpha1"
 )
 
 var log = logf.Log.WithName("manifests")
 
-func NewYamlFile(path string, config *rest.Config) *YamlFile {
+// lastAppliedAnnotation records the configuration YamlFile last sent to the
+// apiserver for a resource, the same convention "kubectl apply" uses, so a
+// later Apply can compute a three-way merge against it instead of a naive
+// two-way diff against whatever's live (which may have drifted from fields
+// a different client owns).
+const lastAppliedAnnotation = "serving.knative.dev/last-applied-configuration"
+
+// builtinGroups are the API groups the apiserver itself knows the patch
+// strategy (patchStrategy/patchMergeKey struct tags) for. CRDs, lacking
+// that metadata, can only be three-way merged as plain JSON.
+var builtinGroups = map[string]bool{
+	"":                          true, // core
+	"apps":                      true,
+	"batch":                     true,
+	"rbac.authorization.k8s.io": true,
+	"policy":                    true,
+	"networking.k8s.io":         true,
+	"extensions":                true,
+	"autoscaling":               true,
+	"apiextensions.k8s.io":      true,
+}
+
+func NewYamlFile(path string, config *rest.Config, scheme *runtime.Scheme) *YamlFile {
 	client, _ := dynamic.NewForConfig(config)
 	log.Info("Reading YAML file", "name", path)
-	return &YamlFile{name: path, resources: parse(path), dynamicClient: client}
+	resources := parse(path)
+	return &YamlFile{
+		name:          path,
+		resources:     resources,
+		dynamicClient: client,
+		mapper:        newMapper(config),
+		scheme:        scheme,
+		hash:          hashResources(resources),
+	}
 }
 
-func (f *YamlFile) Apply(owner *v1.OwnerReference) error {
-	for _, spec := range f.resources {
-		c, err := client(spec, f.dynamicClient)
-		if err != nil {
-			return err
-		}
-		_, err = c.Get(spec.GetName(), v1.GetOptions{})
-		if err == nil {
-			continue
+// Hash is a stable identifier (the hex SHA-256 of the parsed documents) for
+// the manifest's contents, suitable for composing an Install's
+// Status.ManifestVersion.
+func (f *YamlFile) Hash() string {
+	return f.hash
+}
+
+// Apply creates or three-way-merge-patches every resource in the manifest,
+// owned by owner. Every applied resource is stamped with owner's namespace
+// and name (see servingv1alpha1.InstallNamespaceLabelKey/InstallNameLabelKey)
+// so the controller can find it again with a label-based watch, since
+// cluster-scoped resources can't carry an owner reference back to a
+// namespaced Install. Before being applied, each resource is run through
+// Transformers, in order, giving callers a chance to overlay user-supplied
+// values (or rewrite image references, inject env, etc.) onto the parsed
+// manifest.
+func (f *YamlFile) Apply(owner *servingv1alpha1.Install, ownerRef *v1.OwnerReference) error {
+	for i := range f.resources {
+		// DeepCopy first: Unstructured.Object is a map, so ranging over
+		// f.resources by value still shares it with the long-lived YamlFile,
+		// and every mutation below (transformers, labels, the last-applied
+		// annotation) would otherwise permanently stack onto the parsed
+		// manifest and get re-applied, and re-stamped, on every reconcile.
+		spec := f.resources[i].DeepCopy()
+		for _, transform := range f.Transformers {
+			if err := transform(spec); err != nil {
+				return err
+			}
 		}
-		if !errors.IsNotFound(err) {
+		c, mapping, err := f.resourceFor(*spec)
+		if err != nil {
 			return err
 		}
-		if !isClusterScoped(spec.GetKind()) {
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
 			// apparently reference counting for cluster-scoped
 			// resources is broken, so trust the GC only for ns-scoped
 			// dependents
-			spec.SetOwnerReferences([]v1.OwnerReference{*owner})
+			spec.SetOwnerReferences([]v1.OwnerReference{*ownerRef})
 		}
-		_, err = c.Create(&spec, v1.CreateOptions{})
+		labels := spec.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[servingv1alpha1.InstallNamespaceLabelKey] = owner.GetNamespace()
+		labels[servingv1alpha1.InstallNameLabelKey] = owner.GetName()
+		spec.SetLabels(labels)
+		if err := stampPodTemplateLabels(spec, labels); err != nil {
+			return err
+		}
+
+		current, err := c.Get(spec.GetName(), v1.GetOptions{})
 		if err != nil {
-			if errors.IsAlreadyExists(err) {
-				continue
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			if err := stampLastApplied(spec); err != nil {
+				return err
+			}
+			if _, err := c.Create(spec, v1.CreateOptions{}); err != nil {
+				if errors.IsAlreadyExists(err) {
+					continue
+				}
+				return err
 			}
+			log.Info("Created resource", "type", spec.GroupVersionKind(), "name", spec.GetName())
+			continue
+		}
+
+		patch, patchType, err := f.threeWayPatch(current, spec)
+		if err != nil {
 			return err
 		}
-		log.Info("Created resource", "type", spec.GroupVersionKind(), "name", spec.GetName())
+		if patch == nil {
+			continue
+		}
+		if _, err := c.Patch(spec.GetName(), patchType, patch, v1.PatchOptions{}); err != nil {
+			return err
+		}
+		log.Info("Patched resource", "type", spec.GroupVersionKind(), "name", spec.GetName())
+	}
+	return nil
+}
+
+// workloadKinds are the manifest kinds whose Pods the install controller
+// tracks (see the watches registered in pkg/controller/install), so their
+// spec.template.metadata.labels need the same Install stamp as the workload
+// object itself, not just metadata.labels.
+var workloadKinds = map[string]bool{
+	"Deployment":  true,
+	"DaemonSet":   true,
+	"StatefulSet": true,
+}
+
+// stampPodTemplateLabels merges labels into spec's pod template metadata,
+// for the workload kinds that carry one, so the Pods a Deployment/DaemonSet/
+// StatefulSet creates are themselves stamped and picked up by the
+// controller's Pod watch and installSelector-based List, not just the
+// workload object itself.
+func stampPodTemplateLabels(spec *unstructured.Unstructured, labels map[string]string) error {
+	if !workloadKinds[spec.GetKind()] {
+		return nil
 	}
+	templateLabels, _, err := unstructured.NestedStringMap(spec.Object, "spec", "template", "metadata", "labels")
+	if err != nil {
+		return err
+	}
+	if templateLabels == nil {
+		templateLabels = map[string]string{}
+	}
+	for k, v := range labels {
+		templateLabels[k] = v
+	}
+	merged := make(map[string]interface{}, len(templateLabels))
+	for k, v := range templateLabels {
+		merged[k] = v
+	}
+	return unstructured.SetNestedMap(spec.Object, merged, "spec", "template", "metadata", "labels")
+}
+
+// stampLastApplied records spec's configuration in the last-applied
+// annotation so a later Apply can three-way merge against it. Any
+// last-applied annotation already on spec is stripped before marshaling, the
+// same way kubectl does, so the annotation doesn't end up nesting a copy of
+// itself and growing unbounded across reconciles.
+func stampLastApplied(spec *unstructured.Unstructured) error {
+	annotations := spec.GetAnnotations()
+	delete(annotations, lastAppliedAnnotation)
+	spec.SetAnnotations(annotations)
+	raw, err := json.Marshal(spec.Object)
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[lastAppliedAnnotation] = string(raw)
+	spec.SetAnnotations(annotations)
 	return nil
 }
 
-func (f *YamlFile) Delete() error {
+// threeWayPatch diffs current's last-applied annotation, current itself and
+// desired, returning the patch (and its PatchType) needed to bring current
+// to desired, or a nil patch if there's nothing to do. Built-in kinds are
+// patched with a strategic merge patch (so e.g. containers merge by name
+// rather than replacing the whole slice); everything else, lacking that
+// metadata, with a plain JSON merge patch.
+func (f *YamlFile) threeWayPatch(current, desired *unstructured.Unstructured) ([]byte, types.PatchType, error) {
+	if err := stampLastApplied(desired); err != nil {
+		return nil, "", err
+	}
+	modified, err := json.Marshal(desired.Object)
+	if err != nil {
+		return nil, "", err
+	}
+	currentJSON, err := json.Marshal(current.Object)
+	if err != nil {
+		return nil, "", err
+	}
+	original := []byte(current.GetAnnotations()[lastAppliedAnnotation])
+	if len(original) == 0 {
+		// We've never applied this resource before (e.g. it predates this
+		// annotation); fall back to a two-way diff against its live state.
+		original = currentJSON
+	}
+
+	gvk := desired.GroupVersionKind()
+	if builtinGroups[gvk.Group] {
+		if obj, err := f.scheme.New(gvk); err == nil {
+			patchMeta, err := strategicpatch.NewPatchMetaFromStruct(obj)
+			if err != nil {
+				return nil, "", err
+			}
+			patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, currentJSON, patchMeta, true)
+			if err != nil {
+				return nil, "", err
+			}
+			if isEmptyPatch(patch) {
+				return nil, "", nil
+			}
+			return patch, types.StrategicMergePatchType, nil
+		}
+	}
+	patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, currentJSON)
+	if err != nil {
+		return nil, "", err
+	}
+	if isEmptyPatch(patch) {
+		return nil, "", nil
+	}
+	return patch, types.MergePatchType, nil
+}
+
+func isEmptyPatch(patch []byte) bool {
+	return len(patch) == 0 || string(patch) == "{}"
+}
+
+// Uninstall deletes every resource in the manifest, in reverse order, for
+// the install finalizer. skip is consulted for each resource so a
+// cluster-scoped one that another Install still depends on (they don't
+// carry an owner reference, so normal GC won't protect them) can be left
+// alone.
+func (f *YamlFile) Uninstall(skip func(servingv1alpha1.ResourceRef) bool) error {
 	a := make([]unstructured.Unstructured, len(f.resources))
 	copy(a, f.resources)
 	// we want to delete in reverse order
@@ -64,17 +278,60 @@ func (f *YamlFile) Delete() error {
 		a[left], a[right] = a[right], a[left]
 	}
 	for _, spec := range a {
-		c, err := client(spec, f.dynamicClient)
+		if skip(refFor(spec)) {
+			log.Info("Skipping resource still referenced by another Install", "type", spec.GroupVersionKind(), "name", spec.GetName())
+			continue
+		}
+		c, _, err := f.resourceFor(spec)
 		if err != nil {
 			return err
 		}
 		log.Info("Deleting resource", "type", spec.GroupVersionKind(), "name", spec.GetName())
-		c.Delete(spec.GetName(), &v1.DeleteOptions{})
-		// ignore GC race conditions triggered by owner references
+		if err := c.Delete(spec.GetName(), &v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
 	}
 	return nil
 }
 
+// Exists reports whether ref is still present in the cluster, retrying
+// transient errors with backoff so a flaky apiserver round-trip doesn't make
+// the install finalizer think a resource survived deletion.
+func (f *YamlFile) Exists(ref servingv1alpha1.ResourceRef) (bool, error) {
+	mapping, err := f.mapper.RESTMapping(schema.GroupKind{Group: ref.Group, Kind: ref.Kind}, ref.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	var c dynamic.ResourceInterface
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		c = f.dynamicClient.Resource(mapping.Resource).Namespace(ref.Namespace)
+	} else {
+		c = f.dynamicClient.Resource(mapping.Resource)
+	}
+	found := false
+	backoff := wait.Backoff{Duration: 100 * time.Millisecond, Factor: 2, Steps: 5}
+	err = wait.ExponentialBackoff(backoff, func() (bool, error) {
+		_, err := c.Get(ref.Name, v1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			found = false
+			return true, nil
+		case err != nil:
+			return false, nil // transient; keep retrying until backoff is exhausted
+		default:
+			found = true
+			return true, nil
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
 func (f *YamlFile) ResourceNames() []string {
 	var names []string
 	for _, spec := range f.resources {
@@ -83,10 +340,149 @@ func (f *YamlFile) ResourceNames() []string {
 	return names
 }
 
+// GroupVersionResources returns the set of distinct GroupVersionResources
+// present in the manifest, resolved via the RESTMapper, for preflight RBAC
+// checks. A manifest is allowed to bundle a CRD alongside custom resources
+// of that CRD, which can't be mapped until Apply has actually created the
+// CRD; such kinds are skipped here rather than failing the whole check, the
+// same tolerance resourceFor gives them once the manifest is actually applied.
+func (f *YamlFile) GroupVersionResources() ([]schema.GroupVersionResource, error) {
+	seen := map[schema.GroupVersionResource]bool{}
+	var gvrs []schema.GroupVersionResource
+	for _, spec := range f.resources {
+		_, mapping, err := f.resourceFor(spec)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				log.Info("Skipping RBAC check for kind not yet installed", "type", spec.GroupVersionKind())
+				continue
+			}
+			return nil, err
+		}
+		if !seen[mapping.Resource] {
+			seen[mapping.Resource] = true
+			gvrs = append(gvrs, mapping.Resource)
+		}
+	}
+	return gvrs, nil
+}
+
+// ResourceRefs identifies every resource in the manifest by GVK and name,
+// for diffing against a previous manifest version's applied resources.
+func (f *YamlFile) ResourceRefs() []servingv1alpha1.ResourceRef {
+	refs := make([]servingv1alpha1.ResourceRef, len(f.resources))
+	for i, spec := range f.resources {
+		refs[i] = refFor(spec)
+	}
+	return refs
+}
+
+// DeleteStale deletes every resource in previous that's no longer part of
+// the current manifest, so that resources dropped between operator
+// versions don't leak.
+func (f *YamlFile) DeleteStale(previous []servingv1alpha1.ResourceRef) error {
+	current := map[string]bool{}
+	for _, ref := range f.ResourceRefs() {
+		current[refKey(ref)] = true
+	}
+	for _, ref := range previous {
+		if current[refKey(ref)] {
+			continue
+		}
+		groupKind := schema.GroupKind{Group: ref.Group, Kind: ref.Kind}
+		mapping, err := f.mapper.RESTMapping(groupKind, ref.Version)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				log.Info("Skipping stale resource of unknown kind", "type", groupKind, "name", ref.Name)
+				continue
+			}
+			return err
+		}
+		var c dynamic.ResourceInterface
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			c = f.dynamicClient.Resource(mapping.Resource).Namespace(ref.Namespace)
+		} else {
+			c = f.dynamicClient.Resource(mapping.Resource)
+		}
+		log.Info("Deleting resource no longer in manifest", "type", groupKind, "name", ref.Name)
+		if err := c.Delete(ref.Name, &v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func refFor(spec unstructured.Unstructured) servingv1alpha1.ResourceRef {
+	gvk := spec.GroupVersionKind()
+	return servingv1alpha1.ResourceRef{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: spec.GetNamespace(),
+		Name:      spec.GetName(),
+	}
+}
+
+func refKey(ref servingv1alpha1.ResourceRef) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s", ref.Group, ref.Version, ref.Kind, ref.Namespace, ref.Name)
+}
+
+// hashResources hashes the JSON encoding of every parsed document, so the
+// result follows the manifest's contents rather than its formatting.
+func hashResources(resources []unstructured.Unstructured) string {
+	h := sha256.New()
+	for _, spec := range resources {
+		b, err := json.Marshal(spec.Object)
+		if err != nil {
+			log.Error(err, "Unable to hash manifest resource", "name", spec.GetName())
+			return ""
+		}
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 type YamlFile struct {
 	name          string
 	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+	scheme        *runtime.Scheme
 	resources     []unstructured.Unstructured
+	hash          string
+
+	// Transformers let callers mutate each parsed resource before it's
+	// applied, e.g. to overlay an InstallSpec onto the manifest.
+	Transformers []func(*unstructured.Unstructured) error
+}
+
+// resourceFor resolves spec's GVK to a dynamic.ResourceInterface and its
+// RESTMapping via the RESTMapper, retrying once against fresh discovery
+// data if the mapper doesn't yet know about the GVK (e.g. a CRD that was
+// just installed by an earlier resource in this same manifest).
+func (f *YamlFile) resourceFor(spec unstructured.Unstructured) (dynamic.ResourceInterface, *meta.RESTMapping, error) {
+	gvk := spec.GroupVersionKind()
+	mapping, err := f.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		if rm, ok := f.mapper.(meta.ResettableRESTMapper); ok {
+			rm.Reset()
+			mapping, err = f.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return f.dynamicClient.Resource(mapping.Resource).Namespace(spec.GetNamespace()), mapping, nil
+	}
+	return f.dynamicClient.Resource(mapping.Resource), mapping, nil
+}
+
+func newMapper(config *rest.Config) meta.RESTMapper {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		log.Error(err, "Unable to create discovery client for RESTMapper")
+		return nil
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
 }
 
 func parse(filename string) []unstructured.Unstructured {
@@ -142,36 +538,3 @@ func decode(in chan []byte, out chan unstructured.Unstructured) {
 	}
 	close(out)
 }
-
-func pluralize(kind string) string {
-	ret := strings.ToLower(kind)
-	switch {
-	case strings.HasSuffix(ret, "s"):
-		return fmt.Sprintf("%ses", ret)
-	case strings.HasSuffix(ret, "policy"):
-		return fmt.Sprintf("%sies", ret[:len(ret)-1])
-	default:
-		return fmt.Sprintf("%ss", ret)
-	}
-}
-
-func client(spec unstructured.Unstructured, dc dynamic.Interface) (dynamic.ResourceInterface, error) {
-	groupVersion, err := schema.ParseGroupVersion(spec.GetAPIVersion())
-	if err != nil {
-		return nil, err
-	}
-	groupVersionResource := groupVersion.WithResource(pluralize(spec.GetKind()))
-	if ns := spec.GetNamespace(); ns == "" {
-		return dc.Resource(groupVersionResource), nil
-	} else {
-		return dc.Resource(groupVersionResource).Namespace(ns), nil
-	}
-}
-
-func isClusterScoped(kind string) bool {
-	switch strings.ToLower(kind) {
-	case "namespace", "clusterrole", "clusterrolebinding", "customresourcedefinition":
-		return true
-	}
-	return false
-}
@@ -0,0 +1,197 @@
+package manifests
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestYamlFile(t *testing.T) *YamlFile {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme: %v", err)
+	}
+	return &YamlFile{scheme: scheme}
+}
+
+func configMap(name string, data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name},
+		"data":       data,
+	}}
+}
+
+func TestThreeWayPatchBuiltinUsesStrategicMergePatch(t *testing.T) {
+	f := newTestYamlFile(t)
+	current := configMap("config-network", map[string]interface{}{"foo": "bar"})
+	if err := stampLastApplied(current); err != nil {
+		t.Fatalf("stampLastApplied: %v", err)
+	}
+	desired := configMap("config-network", map[string]interface{}{"foo": "baz"})
+
+	patch, patchType, err := f.threeWayPatch(current, desired)
+	if err != nil {
+		t.Fatalf("threeWayPatch returned error: %v", err)
+	}
+	if patchType != types.StrategicMergePatchType {
+		t.Errorf("patchType = %v, want %v", patchType, types.StrategicMergePatchType)
+	}
+	if isEmptyPatch(patch) {
+		t.Errorf("patch is empty, want a patch changing foo")
+	}
+}
+
+func TestThreeWayPatchCRDUsesMergePatch(t *testing.T) {
+	f := newTestYamlFile(t)
+	current := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1alpha1",
+		"kind":       "Revision",
+		"metadata":   map[string]interface{}{"name": "rev-1"},
+		"spec":       map[string]interface{}{"containerConcurrency": int64(1)},
+	}}
+	if err := stampLastApplied(current); err != nil {
+		t.Fatalf("stampLastApplied: %v", err)
+	}
+	desired := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1alpha1",
+		"kind":       "Revision",
+		"metadata":   map[string]interface{}{"name": "rev-1"},
+		"spec":       map[string]interface{}{"containerConcurrency": int64(2)},
+	}}
+
+	patch, patchType, err := f.threeWayPatch(current, desired)
+	if err != nil {
+		t.Fatalf("threeWayPatch returned error: %v", err)
+	}
+	if patchType != types.MergePatchType {
+		t.Errorf("patchType = %v, want %v", patchType, types.MergePatchType)
+	}
+	if isEmptyPatch(patch) {
+		t.Errorf("patch is empty, want a patch changing containerConcurrency")
+	}
+}
+
+func TestThreeWayPatchNoopWhenUnchanged(t *testing.T) {
+	f := newTestYamlFile(t)
+	current := configMap("config-network", map[string]interface{}{"foo": "bar"})
+	if err := stampLastApplied(current); err != nil {
+		t.Fatalf("stampLastApplied: %v", err)
+	}
+	desired := configMap("config-network", map[string]interface{}{"foo": "bar"})
+
+	patch, _, err := f.threeWayPatch(current, desired)
+	if err != nil {
+		t.Fatalf("threeWayPatch returned error: %v", err)
+	}
+	if !isEmptyPatch(patch) {
+		t.Errorf("patch = %s, want an empty/no-op patch", patch)
+	}
+}
+
+// TestStampLastAppliedDoesNotNestPreviousAnnotation is a regression test: a
+// prior version of stampLastApplied marshaled spec.Object before stripping
+// its own previous last-applied annotation, so the annotation grew to
+// contain a copy of itself on every call, eventually exceeding the 256 KB
+// annotation limit.
+func TestStampLastAppliedDoesNotNestPreviousAnnotation(t *testing.T) {
+	spec := configMap("config-network", map[string]interface{}{"foo": "bar"})
+
+	if err := stampLastApplied(spec); err != nil {
+		t.Fatalf("stampLastApplied: %v", err)
+	}
+	first := spec.GetAnnotations()[lastAppliedAnnotation]
+
+	if err := stampLastApplied(spec); err != nil {
+		t.Fatalf("stampLastApplied: %v", err)
+	}
+	second := spec.GetAnnotations()[lastAppliedAnnotation]
+
+	if len(second) > len(first)+len(lastAppliedAnnotation)+10 {
+		t.Errorf("last-applied annotation grew across repeated stamps: first=%d bytes second=%d bytes", len(first), len(second))
+	}
+	if first == second {
+		// harmless if the content happens to be identical, but the
+		// important assertion above is that it doesn't grow unbounded
+		t.Logf("annotation unchanged across repeated stamps, as expected: %s", second)
+	}
+}
+
+// TestStampingDeepCopyDoesNotMutateParsedManifest guards the DeepCopy Apply
+// now takes of each parsed resource: Apply used to range over f.resources by
+// value, which still shared each unstructured.Unstructured's underlying
+// Object map, so label/annotation stamping leaked back into the long-lived
+// parsed manifest and compounded on every reconcile. It stamps a DeepCopy
+// the way Apply does, without calling Apply itself.
+func TestStampingDeepCopyDoesNotMutateParsedManifest(t *testing.T) {
+	f := newTestYamlFile(t)
+	f.resources = []unstructured.Unstructured{*configMap("config-network", map[string]interface{}{"foo": "bar"})}
+
+	spec := f.resources[0].DeepCopy()
+	if err := stampLastApplied(spec); err != nil {
+		t.Fatalf("stampLastApplied: %v", err)
+	}
+	spec.SetLabels(map[string]string{"serving.knative.dev/install-name": "my-install"})
+
+	if _, ok := f.resources[0].GetAnnotations()[lastAppliedAnnotation]; ok {
+		t.Errorf("stamping a DeepCopy leaked the last-applied annotation back onto f.resources")
+	}
+	if len(f.resources[0].GetLabels()) != 0 {
+		t.Errorf("stamping a DeepCopy leaked labels back onto f.resources: %v", f.resources[0].GetLabels())
+	}
+}
+
+func TestStampPodTemplateLabelsStampsWorkloadKinds(t *testing.T) {
+	spec := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "controller"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "controller"}},
+			},
+		},
+	}}
+
+	err := stampPodTemplateLabels(spec, map[string]string{"serving.knative.dev/install-name": "my-install"})
+	if err != nil {
+		t.Fatalf("stampPodTemplateLabels returned error: %v", err)
+	}
+
+	got, _, _ := unstructured.NestedStringMap(spec.Object, "spec", "template", "metadata", "labels")
+	if got["app"] != "controller" {
+		t.Errorf("existing pod template label dropped: %v", got)
+	}
+	if got["serving.knative.dev/install-name"] != "my-install" {
+		t.Errorf("install-name label not stamped onto pod template: %v", got)
+	}
+}
+
+func TestStampPodTemplateLabelsIgnoresNonWorkloadKinds(t *testing.T) {
+	spec := configMap("config-network", map[string]interface{}{"foo": "bar"})
+
+	if err := stampPodTemplateLabels(spec, map[string]string{"serving.knative.dev/install-name": "my-install"}); err != nil {
+		t.Fatalf("stampPodTemplateLabels returned error: %v", err)
+	}
+
+	if _, found, _ := unstructured.NestedMap(spec.Object, "spec"); found {
+		t.Errorf("stampPodTemplateLabels added a spec field to a ConfigMap")
+	}
+}
+
+func TestIsEmptyPatch(t *testing.T) {
+	cases := map[string]bool{
+		"":        true,
+		"{}":      true,
+		`{"a":1}`: false,
+	}
+	for patch, want := range cases {
+		if got := isEmptyPatch([]byte(patch)); got != want {
+			t.Errorf("isEmptyPatch(%q) = %v, want %v", patch, got, want)
+		}
+	}
+}